@@ -0,0 +1,165 @@
+package gresty
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"golang.org/x/sync/singleflight"
+)
+
+// tokenSkew 提前多久视为令牌过期，避免请求发出的瞬间令牌刚好失效
+const tokenSkew = 10 * time.Second
+
+// TokenSource 访问令牌来源，Service.WithTokenSource依赖该接口自动注入Authorization: Bearer <token>
+type TokenSource interface {
+	// Token 返回一个可用的访问令牌，以及该令牌的过期时间
+	Token() (token string, expiresAt time.Time, err error)
+}
+
+// cachedToken Service内部缓存的令牌状态，goroutine-safe
+type cachedToken struct {
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+	group     singleflight.Group
+}
+
+// WithTokenSource 给Service设置令牌来源，每次请求前会自动注入Authorization: Bearer <token>
+// 令牌会被缓存到过期前tokenSkew，并通过singleflight避免并发请求时重复刷新令牌
+func WithTokenSource(ts TokenSource) Option {
+	return func(s *Service) {
+		s.tokenSource = ts
+		s.cachedToken = &cachedToken{}
+	}
+}
+
+// applyAuth 注入认证信息，RequestOptions.BearerToken优先级高于Service.tokenSource
+func (s *Service) applyAuth(client *resty.Client, req *RequestOptions) error {
+	if req.BearerToken != "" {
+		client.SetAuthToken(req.BearerToken)
+		return nil
+	}
+
+	if s.tokenSource == nil {
+		return nil
+	}
+
+	token, err := s.token()
+	if err != nil {
+		return fmt.Errorf("get token error: %w", err)
+	}
+
+	client.SetAuthToken(token)
+	return nil
+}
+
+// token 获取当前可用的访问令牌，必要时刷新，goroutine-safe
+func (s *Service) token() (string, error) {
+	ct := s.cachedToken
+
+	ct.mu.Lock()
+	if ct.token != "" && time.Now().Before(ct.expiresAt) {
+		token := ct.token
+		ct.mu.Unlock()
+		return token, nil
+	}
+	ct.mu.Unlock()
+
+	// 并发场景下只允许一个goroutine真正去刷新令牌，其他goroutine等待结果
+	v, err, _ := ct.group.Do("token", func() (interface{}, error) {
+		ct.mu.Lock()
+		if ct.token != "" && time.Now().Before(ct.expiresAt) {
+			token := ct.token
+			ct.mu.Unlock()
+			return token, nil
+		}
+		ct.mu.Unlock()
+
+		token, expiresAt, err := s.tokenSource.Token()
+		if err != nil {
+			return "", err
+		}
+
+		ct.mu.Lock()
+		ct.token = token
+		ct.expiresAt = expiresAt.Add(-tokenSkew)
+		ct.mu.Unlock()
+
+		return token, nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return v.(string), nil
+}
+
+// invalidateToken 清空缓存的令牌，使下一次请求强制刷新，用于上游返回401时的重试
+func (s *Service) invalidateToken() {
+	ct := s.cachedToken
+
+	ct.mu.Lock()
+	ct.token = ""
+	ct.expiresAt = time.Time{}
+	ct.mu.Unlock()
+}
+
+// clientCredentialsResp token接口返回的标准OAuth2响应
+type clientCredentialsResp struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+// ClientCredentialsTokenSource 基于OAuth2 client_credentials模式获取访问令牌
+// POST grant_type=client_credentials到TokenURL换取access_token
+type ClientCredentialsTokenSource struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scope        string
+
+	// client 用于请求token接口，跟业务client分开，避免共用熔断器/限流等状态
+	client *resty.Client
+}
+
+// Token 实现TokenSource接口
+func (c *ClientCredentialsTokenSource) Token() (string, time.Time, error) {
+	if c.client == nil {
+		c.client = resty.New()
+	}
+
+	data := map[string]string{
+		"grant_type":    "client_credentials",
+		"client_id":     c.ClientID,
+		"client_secret": c.ClientSecret,
+	}
+	if c.Scope != "" {
+		data["scope"] = c.Scope
+	}
+
+	resp, err := c.client.R().SetFormData(data).Post(c.TokenURL)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if resp.IsError() {
+		return "", time.Time{}, fmt.Errorf("token request failed, status code: %d, body: %s", resp.StatusCode(), resp.Body())
+	}
+
+	var tr clientCredentialsResp
+	if err := json.Unmarshal(resp.Body(), &tr); err != nil {
+		return "", time.Time{}, err
+	}
+
+	if tr.AccessToken == "" {
+		return "", time.Time{}, errors.New("token response missing access_token")
+	}
+
+	return tr.AccessToken, time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second), nil
+}
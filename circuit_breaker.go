@@ -0,0 +1,353 @@
+package gresty
+
+import (
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断器处于打开状态，请求被直接拒绝，没有真正发往resty
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// breakerState 熔断器状态
+type breakerState int32
+
+const (
+	breakerClosed   breakerState = iota // 关闭状态，请求正常放行
+	breakerOpen                         // 打开状态，请求被直接拒绝
+	breakerHalfOpen                     // 半开状态，放行少量探测请求
+)
+
+// String 返回状态的可读名称，用于CircuitStats对外展示
+func (st breakerState) String() string {
+	switch st {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreakerConfig 熔断器配置
+type CircuitBreakerConfig struct {
+	FailureThreshold float64       // 失败率阈值，超过该比例触发熔断，默认0.5
+	MinRequests      int64         // 触发熔断判断所需的最小请求数，默认20
+	CoolDown         time.Duration // 熔断打开后的初始冷却时间，默认5s
+	MaxCoolDown      time.Duration // 冷却时间上限，每次重新打开指数递增，默认1分钟
+	HalfOpenProbes   int64         // half-open状态下允许放行的探测请求数，默认1
+	SuccessQuorum    int64         // half-open状态下需要连续成功的探测数才能关闭熔断，默认1
+
+	// WindowDuration closed状态下失败率统计所覆盖的滑动窗口长度，默认10s，
+	// 窗口内部按windowBuckets个时间片滚动统计，窗口之外的历史请求不再影响失败率判断
+	WindowDuration time.Duration
+}
+
+// CircuitBreakerOption 熔断器配置项，采用functional options模式
+type CircuitBreakerOption func(c *CircuitBreakerConfig)
+
+// WithFailureThreshold 设置触发熔断的失败率阈值，取值范围(0,1]
+func WithFailureThreshold(ratio float64) CircuitBreakerOption {
+	return func(c *CircuitBreakerConfig) {
+		c.FailureThreshold = ratio
+	}
+}
+
+// WithMinRequests 设置触发熔断判断所需的最小请求数
+func WithMinRequests(n int64) CircuitBreakerOption {
+	return func(c *CircuitBreakerConfig) {
+		c.MinRequests = n
+	}
+}
+
+// WithCoolDown 设置熔断打开后的初始冷却时间
+func WithCoolDown(d time.Duration) CircuitBreakerOption {
+	return func(c *CircuitBreakerConfig) {
+		c.CoolDown = d
+	}
+}
+
+// WithMaxCoolDown 设置冷却时间的上限
+func WithMaxCoolDown(d time.Duration) CircuitBreakerOption {
+	return func(c *CircuitBreakerConfig) {
+		c.MaxCoolDown = d
+	}
+}
+
+// WithHalfOpenProbes 设置half-open状态下允许放行的探测请求数
+func WithHalfOpenProbes(n int64) CircuitBreakerOption {
+	return func(c *CircuitBreakerConfig) {
+		c.HalfOpenProbes = n
+	}
+}
+
+// WithSuccessQuorum 设置half-open状态下需要的连续成功探测数
+func WithSuccessQuorum(n int64) CircuitBreakerOption {
+	return func(c *CircuitBreakerConfig) {
+		c.SuccessQuorum = n
+	}
+}
+
+// WithWindow 设置closed状态下失败率统计所覆盖的滑动窗口长度
+func WithWindow(d time.Duration) CircuitBreakerOption {
+	return func(c *CircuitBreakerConfig) {
+		c.WindowDuration = d
+	}
+}
+
+// defaultCircuitBreakerConfig 默认的熔断器配置
+func defaultCircuitBreakerConfig() *CircuitBreakerConfig {
+	return &CircuitBreakerConfig{
+		FailureThreshold: 0.5,
+		MinRequests:      20,
+		CoolDown:         5 * time.Second,
+		MaxCoolDown:      time.Minute,
+		HalfOpenProbes:   1,
+		SuccessQuorum:    1,
+		WindowDuration:   defaultWindowDuration,
+	}
+}
+
+// WithCircuitBreaker 给Service启用熔断器，按请求url的host维度隔离状态
+// 熔断器的判定发生在重试之前，打开状态下重试不会绕过熔断器
+func WithCircuitBreaker(opts ...CircuitBreakerOption) Option {
+	cfg := defaultCircuitBreakerConfig()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(s *Service) {
+		s.breakerCfg = cfg
+		s.breakers = &sync.Map{}
+	}
+}
+
+const (
+	// defaultWindowDuration CircuitBreakerConfig.WindowDuration的默认值
+	defaultWindowDuration = 10 * time.Second
+
+	// windowBuckets 滑动窗口划分的时间片数量，每个时间片跨度为WindowDuration/windowBuckets
+	windowBuckets = 10
+)
+
+// bucket 滑动窗口中一个时间片的统计数据，slot标识该时间片对应的绝对时间编号，
+// 用于判断这片数据是否已经滚出了窗口
+type bucket struct {
+	slot   int64
+	total  int64
+	failed int64
+}
+
+// breaker 单个host维度的熔断器状态，所有字段都在mu保护下读写
+type breaker struct {
+	cfg *CircuitBreakerConfig
+
+	mu           sync.Mutex
+	state        breakerState
+	coolDown     time.Duration // 当前生效的冷却时间，每次重新打开指数递增
+	openedAt     time.Time
+	halfOpenSeen int64 // half-open状态下已经放行的探测请求数
+	halfOpenGood int64 // half-open状态下探测成功的请求数
+
+	// buckets closed状态下滑动窗口的请求总数/失败数统计，按时间片滚动，
+	// 避免历史成功请求无限期稀释最近的失败率（见windowTotals）
+	buckets [windowBuckets]bucket
+}
+
+// bucketSpan 返回单个时间片的跨度
+func (b *breaker) bucketSpan() time.Duration {
+	span := b.cfg.WindowDuration / windowBuckets
+	if span <= 0 {
+		span = defaultWindowDuration / windowBuckets
+	}
+
+	return span
+}
+
+// currentBucket 返回now所在的时间片，如果该时间片已经被之前更早的一轮窗口占用过，先清零
+func (b *breaker) currentBucket(now time.Time) *bucket {
+	slot := now.UnixNano() / int64(b.bucketSpan())
+	bk := &b.buckets[slot%windowBuckets]
+	if bk.slot != slot {
+		bk.slot = slot
+		bk.total = 0
+		bk.failed = 0
+	}
+
+	return bk
+}
+
+// windowTotals 汇总当前仍处于滑动窗口内的时间片，超出窗口范围的旧数据不计入
+func (b *breaker) windowTotals(now time.Time) (total, failed int64) {
+	currentSlot := now.UnixNano() / int64(b.bucketSpan())
+	oldest := currentSlot - windowBuckets
+	for i := range b.buckets {
+		bk := &b.buckets[i]
+		if bk.slot <= oldest {
+			continue
+		}
+
+		total += bk.total
+		failed += bk.failed
+	}
+
+	return total, failed
+}
+
+// resetWindow 清空滑动窗口的统计数据，用于熔断器打开或者探测全部通过关闭之后重新开始统计
+func (b *breaker) resetWindow() {
+	b.buckets = [windowBuckets]bucket{}
+}
+
+// CircuitState 熔断器状态快照，用于CircuitStats对外展示
+type CircuitState struct {
+	Host   string
+	State  string
+	Total  int64
+	Failed int64
+}
+
+// CircuitStats 返回当前所有host维度的熔断器状态快照，未启用熔断器时返回nil
+func (s *Service) CircuitStats() []CircuitState {
+	if s.breakers == nil {
+		return nil
+	}
+
+	var stats []CircuitState
+	s.breakers.Range(func(key, value interface{}) bool {
+		b := value.(*breaker)
+		stats = append(stats, b.snapshot(key.(string)))
+		return true
+	})
+
+	return stats
+}
+
+// snapshot 生成当前状态的一份快照
+func (b *breaker) snapshot(host string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	total, failed := b.windowTotals(time.Now())
+	return CircuitState{
+		Host:   host,
+		State:  b.state.String(),
+		Total:  total,
+		Failed: failed,
+	}
+}
+
+// allow 判断当前请求是否被熔断器放行
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.coolDown {
+			return false
+		}
+
+		// 冷却时间已到，进入half-open状态，重新计数探测请求
+		b.state = breakerHalfOpen
+		b.halfOpenSeen = 0
+		b.halfOpenGood = 0
+		fallthrough
+	case breakerHalfOpen:
+		if b.halfOpenSeen >= b.cfg.HalfOpenProbes {
+			return false
+		}
+
+		b.halfOpenSeen++
+		return true
+	default: // breakerClosed
+		return true
+	}
+}
+
+// record 记录一次请求的成功/失败结果，驱动熔断器状态迁移
+func (b *breaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerHalfOpen:
+		if !success {
+			// 探测失败，重新打开熔断器，冷却时间指数退避
+			b.trip()
+			return
+		}
+
+		b.halfOpenGood++
+		if b.halfOpenGood >= b.cfg.SuccessQuorum {
+			// 探测全部通过，关闭熔断器，重置滑动窗口
+			b.state = breakerClosed
+			b.coolDown = 0
+			b.resetWindow()
+		}
+	case breakerOpen:
+		// 打开状态下的新请求都被allow拦截；熔断器打开之前已经放行、打开之后才完成的请求会走到这里，
+		// 其结果不计入任何统计
+	default: // breakerClosed
+		now := time.Now()
+		bk := b.currentBucket(now)
+		bk.total++
+		if !success {
+			bk.failed++
+		}
+
+		total, failed := b.windowTotals(now)
+		if total >= b.cfg.MinRequests && float64(failed)/float64(total) >= b.cfg.FailureThreshold {
+			b.trip()
+		}
+	}
+}
+
+// trip 将熔断器切换到open状态，冷却时间指数递增，不超过MaxCoolDown
+func (b *breaker) trip() {
+	if b.coolDown == 0 {
+		b.coolDown = b.cfg.CoolDown
+	} else {
+		b.coolDown *= 2
+		if b.coolDown > b.cfg.MaxCoolDown {
+			b.coolDown = b.cfg.MaxCoolDown
+		}
+	}
+
+	b.state = breakerOpen
+	b.openedAt = time.Now()
+	b.resetWindow()
+}
+
+// breakerFor 获取（或创建）host对应的熔断器
+func (s *Service) breakerFor(host string) *breaker {
+	if v, ok := s.breakers.Load(host); ok {
+		return v.(*breaker)
+	}
+
+	b := &breaker{cfg: s.breakerCfg}
+	actual, _ := s.breakers.LoadOrStore(host, b)
+	return actual.(*breaker)
+}
+
+// requestHost 解析url获取host，用于按host维度隔离熔断器状态
+func requestHost(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil || u.Host == "" {
+		return rawUrl
+	}
+
+	return u.Host
+}
+
+// isFailure 判断一次请求结果是否应当计入熔断器的失败统计
+// 非2xx状态码，超时，连接错误都视为失败
+func isFailure(reply *Reply) bool {
+	if reply.Err != nil {
+		return true
+	}
+
+	return reply.StatusCode < 200 || reply.StatusCode >= 300
+}
@@ -0,0 +1,157 @@
+package gresty
+
+import (
+	"testing"
+	"time"
+)
+
+func testBreakerConfig() *CircuitBreakerConfig {
+	return &CircuitBreakerConfig{
+		FailureThreshold: 0.5,
+		MinRequests:      2,
+		CoolDown:         20 * time.Millisecond,
+		MaxCoolDown:      100 * time.Millisecond,
+		HalfOpenProbes:   1,
+		SuccessQuorum:    1,
+		WindowDuration:   time.Second,
+	}
+}
+
+// TestBreakerStateMachine 覆盖closed->open->half-open->closed这条最常见的状态迁移路径
+func TestBreakerStateMachine(t *testing.T) {
+	cfg := testBreakerConfig()
+	b := &breaker{cfg: cfg}
+
+	if !b.allow() {
+		t.Fatalf("expected a fresh closed breaker to allow requests")
+	}
+
+	b.record(false)
+	b.record(false) // 2/2失败，达到MinRequests且失败率100% >= 50%，应该触发熔断
+
+	if b.state != breakerOpen {
+		t.Fatalf("expected breaker to trip to open, got %v", b.state)
+	}
+
+	if b.allow() {
+		t.Fatalf("expected open breaker to reject requests before cooldown elapses")
+	}
+
+	time.Sleep(cfg.CoolDown + 5*time.Millisecond)
+
+	if !b.allow() {
+		t.Fatalf("expected breaker to allow a probe request once cooldown has elapsed")
+	}
+
+	if b.state != breakerHalfOpen {
+		t.Fatalf("expected breaker to move to half-open, got %v", b.state)
+	}
+
+	if b.allow() {
+		t.Fatalf("expected half-open breaker to reject a second probe once HalfOpenProbes is exhausted")
+	}
+
+	b.record(true) // 探测成功，SuccessQuorum=1，应该关闭熔断器
+
+	if b.state != breakerClosed {
+		t.Fatalf("expected breaker to close after a successful probe, got %v", b.state)
+	}
+}
+
+// TestBreakerHalfOpenFailureReopensWithBackoff 覆盖探测失败时重新打开并指数退避冷却时间
+func TestBreakerHalfOpenFailureReopensWithBackoff(t *testing.T) {
+	cfg := testBreakerConfig()
+	cfg.MinRequests = 1
+	b := &breaker{cfg: cfg}
+
+	b.record(false) // 1/1失败 -> 触发熔断
+	if b.state != breakerOpen {
+		t.Fatalf("expected breaker to trip open after a single failing request")
+	}
+
+	firstCoolDown := b.coolDown
+
+	time.Sleep(cfg.CoolDown + 5*time.Millisecond)
+	if !b.allow() {
+		t.Fatalf("expected probe request to be allowed after cooldown")
+	}
+
+	b.record(false) // 探测失败，重新打开
+	if b.state != breakerOpen {
+		t.Fatalf("expected breaker to reopen after a failed probe")
+	}
+
+	if b.coolDown <= firstCoolDown {
+		t.Fatalf("expected cooldown to back off exponentially, got %v after %v", b.coolDown, firstCoolDown)
+	}
+
+	if b.coolDown > cfg.MaxCoolDown {
+		t.Fatalf("expected cooldown to be capped at MaxCoolDown(%v), got %v", cfg.MaxCoolDown, b.coolDown)
+	}
+}
+
+// TestBreakerStaysClosedBelowMinRequests 覆盖请求量不足MinRequests时即使全部失败也不应该触发熔断
+func TestBreakerStaysClosedBelowMinRequests(t *testing.T) {
+	cfg := testBreakerConfig()
+	cfg.MinRequests = 10
+	b := &breaker{cfg: cfg}
+
+	for i := 0; i < 5; i++ {
+		b.record(false)
+	}
+
+	if b.state != breakerClosed {
+		t.Fatalf("expected breaker to stay closed below MinRequests, got %v", b.state)
+	}
+}
+
+// TestBreakerWindowTotals 验证滑动窗口只统计仍处于窗口内的时间片，
+// 这是本次修复的核心：closed状态的失败率不应该被窗口之外的历史请求影响
+func TestBreakerWindowTotals(t *testing.T) {
+	cfg := &CircuitBreakerConfig{WindowDuration: windowBuckets * time.Second} // 每个时间片跨度1s
+	b := &breaker{cfg: cfg}
+
+	base := time.Unix(1_700_000_000, 0)
+
+	bk := b.currentBucket(base)
+	bk.total = 3
+	bk.failed = 2
+
+	cases := []struct {
+		name       string
+		now        time.Time
+		wantTotal  int64
+		wantFailed int64
+	}{
+		{name: "same bucket", now: base, wantTotal: 3, wantFailed: 2},
+		{name: "still inside window", now: base.Add(5 * time.Second), wantTotal: 3, wantFailed: 2},
+		{name: "exactly one window later falls outside", now: base.Add(windowBuckets * time.Second), wantTotal: 0, wantFailed: 0},
+		{name: "well outside window", now: base.Add(time.Minute), wantTotal: 0, wantFailed: 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			total, failed := b.windowTotals(c.now)
+			if total != c.wantTotal || failed != c.wantFailed {
+				t.Fatalf("windowTotals(%v) = (%d, %d), want (%d, %d)", c.now, total, failed, c.wantTotal, c.wantFailed)
+			}
+		})
+	}
+}
+
+// TestBreakerCurrentBucketResetsStaleSlot 验证时间片被一整个窗口周期之后的请求复用时会被清零，
+// 而不是把很久以前的统计继续累加下去
+func TestBreakerCurrentBucketResetsStaleSlot(t *testing.T) {
+	cfg := &CircuitBreakerConfig{WindowDuration: windowBuckets * time.Second}
+	b := &breaker{cfg: cfg}
+
+	base := time.Unix(1_700_000_000, 0)
+	bk := b.currentBucket(base)
+	bk.total, bk.failed = 5, 5
+
+	later := base.Add(windowBuckets * time.Second) // 落在同一个桶位上，但已经是整整一个窗口之后
+	bk2 := b.currentBucket(later)
+	if bk2.total != 0 || bk2.failed != 0 {
+		t.Fatalf("expected stale bucket to be reset, got total=%d failed=%d", bk2.total, bk2.failed)
+	}
+}
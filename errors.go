@@ -0,0 +1,110 @@
+package gresty
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// 连接层面的错误分类，可以配合errors.Is使用，比如:
+//
+//	if errors.Is(reply.Err, gresty.ErrTimeout) {
+//	    // 超时重试或者降级处理
+//	}
+var (
+	ErrTimeout     = errors.New("request timeout")
+	ErrConnRefused = errors.New("connection refused")
+	ErrDNS         = errors.New("dns lookup failed")
+	ErrTLS         = errors.New("tls handshake failed")
+)
+
+// HTTPError 结构化的请求错误，保留状态码，响应body，以及解码后的业务错误，
+// 避免像GetResult之前那样把这些信息丢在fmt.Errorf的字符串里
+type HTTPError struct {
+	StatusCode int         // http状态码
+	Status     string      // http状态文本，比如"404 Not Found"
+	URL        string      // 请求的url
+	Method     string      // 请求的方法
+	Body       []byte      // 响应body
+	Headers    http.Header // 响应header
+
+	// APIError 通过RequestOptions.ErrorModel解析出来的业务错误，未设置ErrorModel时为nil
+	APIError interface{}
+}
+
+// Error 实现error接口
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s %s: status code %d", e.Method, e.URL, e.StatusCode)
+}
+
+// HTTPError 返回结构化的错误信息，请求成功或者在发出前就失败（比如method/url为空）时返回nil
+func (r *Reply) HTTPError() *HTTPError {
+	return r.httpErr
+}
+
+// newHTTPError 根据Reply和resty.Response构造HTTPError
+func newHTTPError(res *Reply, resp *resty.Response) *HTTPError {
+	he := &HTTPError{
+		StatusCode: res.StatusCode,
+		Body:       res.Body,
+		Headers:    res.Headers,
+	}
+
+	if resp != nil {
+		he.Status = resp.Status()
+		he.APIError = resp.Error()
+		if resp.Request != nil {
+			he.Method = resp.Request.Method
+			he.URL = resp.Request.URL
+		}
+	}
+
+	return he
+}
+
+// classifyConnError 根据底层net.OpError/url.Error对连接类错误做分类，
+// 分类后的错误通过%w包装了对应的哨兵错误（ErrTimeout等），方便上层用errors.Is判断，
+// 原始err只以文本形式保留在错误信息里，不在errors.Is/errors.As的解包链上
+func classifyConnError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+
+	if urlErr.Timeout() {
+		return fmt.Errorf("%w: %s", ErrTimeout, err)
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(urlErr.Err, &dnsErr) {
+		return fmt.Errorf("%w: %s", ErrDNS, err)
+	}
+
+	if errors.Is(urlErr.Err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("%w: %s", ErrConnRefused, err)
+	}
+
+	var tlsRecordErr tls.RecordHeaderError
+	if errors.As(urlErr.Err, &tlsRecordErr) {
+		return fmt.Errorf("%w: %s", ErrTLS, err)
+	}
+
+	var certErr x509.UnknownAuthorityError
+	if errors.As(urlErr.Err, &certErr) {
+		return fmt.Errorf("%w: %s", ErrTLS, err)
+	}
+
+	var hostnameErr x509.HostnameError
+	if errors.As(urlErr.Err, &hostnameErr) {
+		return fmt.Errorf("%w: %s", ErrTLS, err)
+	}
+
+	return err
+}
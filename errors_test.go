@@ -0,0 +1,129 @@
+package gresty
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+)
+
+// timeoutErr 实现net.Error风格的Timeout()方法，用于构造url.Error.Timeout()返回true的场景
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string { return "i/o timeout" }
+func (timeoutErr) Timeout() bool { return true }
+
+func TestClassifyConnError(t *testing.T) {
+	plainErr := errors.New("some unrelated error")
+
+	cases := []struct {
+		name    string
+		err     error
+		wantErr error // 为nil表示期望原样返回plainErr/传入的err，不做分类
+	}{
+		{
+			name:    "not a url.Error returns original error",
+			err:     plainErr,
+			wantErr: nil,
+		},
+		{
+			name: "timeout",
+			err: &url.Error{
+				Op:  "Get",
+				URL: "http://example.com",
+				Err: timeoutErr{},
+			},
+			wantErr: ErrTimeout,
+		},
+		{
+			name: "dns lookup failure",
+			err: &url.Error{
+				Op:  "Get",
+				URL: "http://example.com",
+				Err: &net.DNSError{Err: "no such host", Name: "example.com"},
+			},
+			wantErr: ErrDNS,
+		},
+		{
+			name: "connection refused",
+			err: &url.Error{
+				Op:  "Get",
+				URL: "http://example.com",
+				Err: syscall.ECONNREFUSED,
+			},
+			wantErr: ErrConnRefused,
+		},
+		{
+			name: "tls record header error",
+			err: &url.Error{
+				Op:  "Get",
+				URL: "https://example.com",
+				Err: tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"},
+			},
+			wantErr: ErrTLS,
+		},
+		{
+			name: "unknown certificate authority",
+			err: &url.Error{
+				Op:  "Get",
+				URL: "https://example.com",
+				Err: x509.UnknownAuthorityError{},
+			},
+			wantErr: ErrTLS,
+		},
+		{
+			name: "certificate hostname mismatch",
+			err: &url.Error{
+				Op:  "Get",
+				URL: "https://example.com",
+				Err: x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"},
+			},
+			wantErr: ErrTLS,
+		},
+		{
+			name: "url.Error with unrecognized inner error returns original error",
+			err: &url.Error{
+				Op:  "Get",
+				URL: "http://example.com",
+				Err: plainErr,
+			},
+			wantErr: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := classifyConnError(c.err)
+
+			if c.wantErr == nil {
+				if got != c.err {
+					t.Fatalf("classifyConnError(%v) = %v, want original error unchanged", c.err, got)
+				}
+
+				return
+			}
+
+			if !errors.Is(got, c.wantErr) {
+				t.Fatalf("classifyConnError(%v) = %v, want errors.Is(got, %v) to be true", c.err, got, c.wantErr)
+			}
+		})
+	}
+}
+
+// 确保fmt.Errorf("%w: %s", ...)没有把错误信息丢掉，方便定位问题
+func TestClassifyConnErrorMessage(t *testing.T) {
+	err := classifyConnError(&url.Error{
+		Op:  "Get",
+		URL: "http://example.com",
+		Err: timeoutErr{},
+	})
+
+	want := fmt.Sprintf("%s: Get %q: i/o timeout", ErrTimeout, "http://example.com")
+	if err.Error() != want {
+		t.Fatalf("classifyConnError error message = %q, want %q", err.Error(), want)
+	}
+}
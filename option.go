@@ -0,0 +1,34 @@
+package gresty
+
+import "time"
+
+// Option Service配置项，采用functional options模式
+type Option func(s *Service)
+
+// apply 将opts应用到Service上
+func (s *Service) apply(opts []Option) {
+	for _, opt := range opts {
+		opt(s)
+	}
+}
+
+// WithBaseUri 设置请求地址uri的前缀
+func WithBaseUri(baseUri string) Option {
+	return func(s *Service) {
+		s.BaseUri = baseUri
+	}
+}
+
+// WithTimeout 设置请求超时时间
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Service) {
+		s.Timeout = timeout
+	}
+}
+
+// WithKeepAlive 设置是否允许长连接方式请求接口
+func WithKeepAlive(enable bool) Option {
+	return func(s *Service) {
+		s.EnableKeepAlive = enable
+	}
+}
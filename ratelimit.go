@@ -0,0 +1,107 @@
+package gresty
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter 客户端限流器，Wait会一直阻塞直到获取到一个令牌，或者ctx被取消
+// golang.org/x/time/rate.Limiter已经实现了该接口
+type RateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// WithRateLimit 给Service设置全局限流，基于令牌桶算法
+// rps为每秒放行的请求数，burst为令牌桶容量
+func WithRateLimit(rps float64, burst int) Option {
+	return func(s *Service) {
+		s.rateLimiter = rate.NewLimiter(rate.Limit(rps), burst)
+	}
+}
+
+// WithHostRateLimit 给指定host额外设置一个限流器，该host的请求先获取全局限流令牌（如果设置了WithRateLimit），
+// 再获取该host限流器的令牌，因此同时占用全局限流和host限流的配额
+func WithHostRateLimit(host string, rps float64, burst int) Option {
+	return func(s *Service) {
+		if s.hostRateLimiters == nil {
+			s.hostRateLimiters = make(map[string]RateLimiter)
+		}
+
+		s.hostRateLimiters[host] = rate.NewLimiter(rate.Limit(rps), burst)
+	}
+}
+
+// WithMaxConcurrent 限制同时在途的请求数量，基于带缓冲channel实现的信号量
+func WithMaxConcurrent(n int) Option {
+	return func(s *Service) {
+		s.concurrency = make(chan struct{}, n)
+	}
+}
+
+// acquire 依次获取全局限流令牌，host维度限流令牌，以及并发槽位，
+// 三者任意一个因ctx取消而失败都会立即返回error，调用方此时无需调用release
+func (s *Service) acquire(ctx context.Context, host string) (release func(), err error) {
+	if s.rateLimiter != nil {
+		if err = s.rateLimiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if hl, ok := s.hostRateLimiters[host]; ok {
+		if err = hl.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.concurrency == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case s.concurrency <- struct{}{}:
+		return func() { <-s.concurrency }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// defaultRetryAfterMaxWait 开启429重试时，调用方未显式设置RetryMaxWaitTime时采用的默认上限，
+// 见request.go configureClient：resty会把retryAfterFunc解析出来的等待时间截断到RetryMaxWaitTime，
+// 而resty的默认值(2s)会让常见的Retry-After取值(几十秒到几分钟)被默默截断
+const defaultRetryAfterMaxWait = 5 * time.Minute
+
+// retryOn429 命中429状态码时触发重试，配合retryAfterFunc控制重试的等待时间
+func retryOn429(resp *resty.Response, err error) bool {
+	return err == nil && resp != nil && resp.StatusCode() == http.StatusTooManyRequests
+}
+
+// retryAfterFunc 解析响应的Retry-After header（支持秒数或者HTTP-date两种格式），
+// 返回resty下一次重试前应该等待的时间，解析失败或不是429时交给resty按默认的重试间隔处理。
+// 注意：返回值最终仍会被resty截断到当前生效的RetryMaxWaitTime，见defaultRetryAfterMaxWait
+func retryAfterFunc(_ *resty.Client, resp *resty.Response) (time.Duration, error) {
+	if resp == nil || resp.StatusCode() != http.StatusTooManyRequests {
+		return 0, nil
+	}
+
+	ra := resp.Header().Get("Retry-After")
+	if ra == "" {
+		return 0, nil
+	}
+
+	if secs, convErr := strconv.Atoi(ra); convErr == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+
+	if t, parseErr := http.ParseTime(ra); parseErr == nil {
+		if d := time.Until(t); d > 0 {
+			return d, nil
+		}
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,132 @@
+package gresty
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+func newResponse(statusCode int, header http.Header) *resty.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &resty.Response{
+		RawResponse: &http.Response{
+			StatusCode: statusCode,
+			Header:     header,
+		},
+	}
+}
+
+func TestRetryOn429(t *testing.T) {
+	cases := []struct {
+		name string
+		resp *resty.Response
+		err  error
+		want bool
+	}{
+		{
+			name: "429 without error retries",
+			resp: newResponse(http.StatusTooManyRequests, nil),
+			want: true,
+		},
+		{
+			name: "429 with error does not retry",
+			resp: newResponse(http.StatusTooManyRequests, nil),
+			err:  errors.New("boom"),
+			want: false,
+		},
+		{
+			name: "non-429 status does not retry",
+			resp: newResponse(http.StatusInternalServerError, nil),
+			want: false,
+		},
+		{
+			name: "nil response does not retry",
+			resp: nil,
+			want: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := retryOn429(c.resp, c.err); got != c.want {
+				t.Fatalf("retryOn429() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfterFunc(t *testing.T) {
+	cases := []struct {
+		name        string
+		resp        *resty.Response
+		wantZero    bool
+		wantAtLeast time.Duration
+	}{
+		{
+			name:     "nil response",
+			resp:     nil,
+			wantZero: true,
+		},
+		{
+			name:     "non-429 status is ignored",
+			resp:     newResponse(http.StatusOK, http.Header{"Retry-After": []string{"30"}}),
+			wantZero: true,
+		},
+		{
+			name:     "429 without Retry-After header",
+			resp:     newResponse(http.StatusTooManyRequests, nil),
+			wantZero: true,
+		},
+		{
+			name:     "429 with unparseable Retry-After",
+			resp:     newResponse(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"not-a-duration"}}),
+			wantZero: true,
+		},
+		{
+			name:        "429 with Retry-After in seconds",
+			resp:        newResponse(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"120"}}),
+			wantAtLeast: 120 * time.Second,
+		},
+		{
+			name: "429 with Retry-After as HTTP-date in the future",
+			resp: newResponse(http.StatusTooManyRequests, http.Header{
+				"Retry-After": []string{time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)},
+			}),
+			wantAtLeast: 85 * time.Second,
+		},
+		{
+			name: "429 with Retry-After as HTTP-date in the past is ignored",
+			resp: newResponse(http.StatusTooManyRequests, http.Header{
+				"Retry-After": []string{time.Now().Add(-90 * time.Second).UTC().Format(http.TimeFormat)},
+			}),
+			wantZero: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := retryAfterFunc(nil, c.resp)
+			if err != nil {
+				t.Fatalf("retryAfterFunc() returned unexpected error: %v", err)
+			}
+
+			if c.wantZero {
+				if got != 0 {
+					t.Fatalf("retryAfterFunc() = %v, want 0", got)
+				}
+
+				return
+			}
+
+			if got < c.wantAtLeast {
+				t.Fatalf("retryAfterFunc() = %v, want at least %v", got, c.wantAtLeast)
+			}
+		})
+	}
+}
@@ -5,16 +5,20 @@ package gresty
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -34,6 +38,61 @@ type Service struct {
 	Timeout time.Duration // 请求超时限制
 
 	EnableKeepAlive bool // 是否允许长连接方式请求接口，默认短连接方式
+
+	// onBeforeRequest/onAfterResponse 请求中间件，在请求发出前/响应返回后被调用
+	// 用于注入请求id，日志打点，指标上报等，无需fork本库
+	onBeforeRequest []resty.RequestMiddleware
+	onAfterResponse []resty.ResponseMiddleware
+
+	// onSuccess/onError 请求完成后的钩子，与onAfterResponse不同，
+	// 无论是否设置了SetDoNotParseResponse，也无论请求最终是成功还是失败，两者之间必有一个会被调用一次
+	onSuccess []resty.SuccessHook
+	onError   []resty.ErrorHook
+
+	// breakerCfg/breakers 熔断器配置及按host维度隔离的熔断器状态
+	// breakerCfg为nil表示未启用熔断器
+	breakerCfg *CircuitBreakerConfig
+	breakers   *sync.Map
+
+	// tokenSource/cachedToken 令牌来源及其缓存状态，tokenSource为nil表示未启用
+	tokenSource TokenSource
+	cachedToken *cachedToken
+
+	// rateLimiter/hostRateLimiters 全局/按host维度的限流器，均为nil表示未启用限流
+	rateLimiter      RateLimiter
+	hostRateLimiters map[string]RateLimiter
+
+	// concurrency 基于channel实现的并发数信号量，为nil表示未启用并发限制
+	concurrency chan struct{}
+
+	// tracer 用于OpenTelemetry链路追踪，为nil表示未启用
+	tracer trace.Tracer
+
+	// configuredClients 记录已经注册过中间件的*resty.Client，避免Request/Stream
+	// 等方法在同一个调用方复用的client上重复注册onBeforeRequest/onAfterResponse等中间件
+	configuredClients sync.Map
+}
+
+// OnBeforeRequest 注册一个请求发出前执行的中间件
+func (s *Service) OnBeforeRequest(m resty.RequestMiddleware) {
+	s.onBeforeRequest = append(s.onBeforeRequest, m)
+}
+
+// OnAfterResponse 注册一个响应返回后执行的中间件
+// 注意:该中间件在请求出错，或者SetDoNotParseResponse(true)时不会被调用，
+// 需要在所有完成路径上都执行的逻辑请使用OnSuccess/OnError
+func (s *Service) OnAfterResponse(m resty.ResponseMiddleware) {
+	s.onAfterResponse = append(s.onAfterResponse, m)
+}
+
+// OnSuccess 注册一个请求成功后执行的钩子，对应resty的client.OnSuccess
+func (s *Service) OnSuccess(h resty.SuccessHook) {
+	s.onSuccess = append(s.onSuccess, h)
+}
+
+// OnError 注册一个请求失败后执行的钩子，对应resty的client.OnError
+func (s *Service) OnError(h resty.ErrorHook) {
+	s.onError = append(s.onError, h)
 }
 
 // RequestOptions 请求参数设置
@@ -52,10 +111,22 @@ type RequestOptions struct {
 	BasicAuthUser     string
 	BasicAuthPassword string
 
-	RetryCount       int                        // 重试次数
-	RetryWaitTime    time.Duration              // 重试间隔,默认100ms
-	RetryMaxWaitTime time.Duration              // 重试最大等待间隔,默认2s
-	RetryConditions  []resty.RetryConditionFunc // 重试条件，是一个函数切片
+	// BearerToken 单次请求级别的bearer token，优先级高于Service.WithTokenSource
+	BearerToken string
+
+	// ErrorModel 非2xx响应body的解析目标，对应resty的SetError，
+	// 解析后的结果可以通过Reply.HTTPError().APIError拿到
+	ErrorModel interface{}
+
+	RetryCount    int           // 重试次数
+	RetryWaitTime time.Duration // 重试间隔,默认100ms
+
+	// RetryMaxWaitTime 重试最大等待间隔，未设置时默认为defaultRetryAfterMaxWait(5分钟)而不是resty
+	// 自身的2s，因为429响应的Retry-After往往比2s长，resty会把retryAfterFunc算出来的等待时间
+	// 截断到这个值；如果预期Retry-After可能超过5分钟，请显式设置本字段
+	RetryMaxWaitTime time.Duration
+
+	RetryConditions []resty.RetryConditionFunc // 重试条件，是一个函数切片
 
 	Params  map[string]interface{} // get,delete的Params参数
 	Data    map[string]interface{} // post请求form data表单数据
@@ -77,6 +148,23 @@ type RequestOptions struct {
 	// 支持文件上传的参数
 	FileName      string // 文件名称
 	FileParamName string // 文件上传的表单file参数名称
+
+	// FileReader/FileSize 以流的方式上传文件，设置后优先于FileName，
+	// 避免os.ReadFile把整个文件读入内存。
+	// 注意：如果RetryCount>0或者配置了TokenSource（可能触发401后的重新分发），
+	// FileReader必须同时实现io.ReadSeeker，否则这里会直接返回错误——
+	// 流式reader一旦被消费就无法重新发送，非seekable的FileReader只能用于不会重发的请求
+	FileReader io.Reader
+	FileSize   int64
+
+	// OnUploadProgress/OnDownloadProgress 上传/下载进度回调，
+	// 入参分别为已传输的字节数和总字节数，总字节数未知时为-1
+	OnUploadProgress   func(bytes, total int64)
+	OnDownloadProgress func(bytes, total int64)
+
+	// EnableTrace 是否开启请求链路追踪，开启后Reply.Trace会被填充
+	// 对应resty的client.R().EnableTrace()
+	EnableTrace bool
 }
 
 // Reply 请求后的结果
@@ -85,6 +173,32 @@ type Reply struct {
 	StatusCode int    // http request 返回status code
 	Err        error  // 请求过程中，发生的error
 	Body       []byte // 返回的body内容
+
+	Headers  http.Header    // 返回的header头信息
+	Cookies  []*http.Cookie // 返回的cookie信息
+	Duration time.Duration  // 本次请求耗费的总时间,resp.Time()
+	Size     int64          // 返回body的大小,resp.Size()
+
+	// Trace 请求链路耗时信息，只有RequestOptions.EnableTrace为true时才会被填充
+	Trace *TraceInfo
+
+	// httpErr 结构化的错误信息，通过HTTPError()对外暴露
+	httpErr *HTTPError
+}
+
+// TraceInfo 请求链路耗时信息，对应resty.TraceInfo
+// 用于记录DNS解析，建立连接，tls握手，服务端处理等各阶段的耗时情况
+type TraceInfo struct {
+	DNSLookup     time.Duration // DNS解析耗时
+	ConnTime      time.Duration // 从请求开始到建立连接完成的耗时(包含DNSLookup)
+	TCPConnTime   time.Duration // tcp连接耗时
+	TLSHandshake  time.Duration // tls握手耗时
+	ServerTime    time.Duration // 从连接建立完成到收到第一个响应字节的耗时
+	ResponseTime  time.Duration // 从收到第一个响应字节到读完body的耗时
+	TotalTime     time.Duration // 本次请求的总耗时
+	IsConnReused  bool          // 本次请求是否复用了已有连接
+	IsConnWasIdle bool          // 复用的连接在使用前是否处于空闲状态
+	ConnIdleTime  time.Duration // 复用连接在使用前的空闲时间
 }
 
 // Text 返回Reply.Body文本格式
@@ -137,42 +251,14 @@ func (s *Service) NewRestyClient() *resty.Client {
 	return client
 }
 
-// Do 请求方法
-// method string  请求的方法get,post,put,patch,delete,head等
-// uri    string  请求的相对地址，如果BaseUri为空，就必须是完整的url地址
-// opt 	  *RequestOptions 请求参数ReqOpt
-// 短连接的形式请求api
-// 关于如何关闭http connection
-// https:// www.cnblogs.com/cobbliu/p/4517598.html
-func (s *Service) Do(method string, reqUrl string, opt *RequestOptions) *Reply {
-	if method == "" || reqUrl == "" {
-		return &Reply{
-			Err: errors.New("request Method or request url is empty"),
-		}
-	}
-
-	client := s.NewRestyClient()
-	if opt == nil {
-		opt = &RequestOptions{}
-	}
-
-	opt.Method = method
-	opt.Url = reqUrl
-	return s.Request(client, opt)
-}
-
-// Request 请求方法
-// resty.setBody: for struct and map data type defaults to 'application/json'
-// SetBody method sets the request body for the request. It supports various realtime needs as easy.
-// We can say its quite handy or powerful. Supported request body data types is `string`,
-// `[]byte`, `struct`, `map`, `slice` and `io.Reader`. Body value can be pointer or non-pointer.
-// Automatic marshalling for JSON and XML content type, if it is `struct`, `map`, or `slice`.
-//
-// client.R().SetFormData method sets Form parameters and their values in the current request.
-// It's applicable only HTTP method `POST` and `PUT` and requests content type would be
-// set as `application/x-www-form-urlencoded`.
-func (s *Service) Request(client *resty.Client, req *RequestOptions) *Reply {
-	if client == nil {
+// configureClient 按照Service和RequestOptions的配置初始化client
+// 提取出来是因为Request和Stream都需要相同的一套client配置逻辑
+func (s *Service) configureClient(client *resty.Client, req *RequestOptions) *resty.Client {
+	// freshClient为true代表client是本次调用新建的，不存在被caller在多次请求间复用的可能，
+	// 因此中间件可以直接注册，不需要也不应该记录到configuredClients里（否则长期运行的Do/DoContext
+	// 每次都会新建client，configuredClients会无限增长造成内存泄漏）
+	freshClient := client == nil
+	if freshClient {
 		client = s.NewRestyClient()
 	}
 
@@ -191,6 +277,32 @@ func (s *Service) Request(client *resty.Client, req *RequestOptions) *Reply {
 		client = client.SetHeader("Connection", "close")
 	}
 
+	registerHooks := freshClient
+	if !freshClient {
+		// client是调用方传入的、可能在多次请求间复用的*resty.Client（比如Request/Stream的入参），
+		// 中间件注册只在该client第一次被configureClient处理时执行一次，避免越用越多的重复中间件
+		_, already := s.configuredClients.LoadOrStore(client, struct{}{})
+		registerHooks = !already
+	}
+
+	if registerHooks {
+		for _, m := range s.onBeforeRequest {
+			client.OnBeforeRequest(m)
+		}
+
+		for _, m := range s.onAfterResponse {
+			client.OnAfterResponse(m)
+		}
+
+		for _, h := range s.onSuccess {
+			client.OnSuccess(h)
+		}
+
+		for _, h := range s.onError {
+			client.OnError(h)
+		}
+	}
+
 	if req.Proxy != "" {
 		client = client.SetProxy(req.Proxy)
 	}
@@ -206,8 +318,25 @@ func (s *Service) Request(client *resty.Client, req *RequestOptions) *Reply {
 			req.RetryCount = defaultMaxRetries // 最大重试次数
 		}
 
+		// 429限流响应默认也会触发重试，并按Retry-After header控制下一次重试的等待时间。
+		// resty会把retryAfterFunc解析出来的等待时间截断到RetryMaxWaitTime，而resty的默认值(2s)
+		// 远小于常见的Retry-After取值，这里在调用方没有显式设置RetryMaxWaitTime时，
+		// 把上限提到defaultRetryAfterMaxWait，避免长Retry-After被默默截断；
+		// 如果预期Retry-After可能超过defaultRetryAfterMaxWait，请显式设置RetryMaxWaitTime
+		client.AddRetryCondition(retryOn429)
+		client.SetRetryAfter(retryAfterFunc)
+		if req.RetryMaxWaitTime == 0 {
+			req.RetryMaxWaitTime = defaultRetryAfterMaxWait
+		}
+
+		// 文件上传场景下，每次重试前把multipart文件reader seek回起始位置，
+		// 不可seek的reader（比如不支持io.ReadSeeker的FileReader）不受影响，由dispatch的file分支提前拒绝
+		client.SetRetryResetReaders(true)
+
 		if len(req.RetryConditions) > 0 {
-			client.RetryConditions = req.RetryConditions
+			// append而不是覆盖，否则调用方自定义的RetryConditions会把上面AddRetryCondition
+			// 注册的retryOn429顶掉，导致429限流响应不再触发重试，与上面的注释自相矛盾
+			client.RetryConditions = append(client.RetryConditions, req.RetryConditions...)
 		}
 
 		// 重试配置
@@ -255,27 +384,174 @@ func (s *Service) Request(client *resty.Client, req *RequestOptions) *Reply {
 		}
 	}
 
+	return client
+}
+
+// Do 请求方法
+// method string  请求的方法get,post,put,patch,delete,head等
+// uri    string  请求的相对地址，如果BaseUri为空，就必须是完整的url地址
+// opt 	  *RequestOptions 请求参数ReqOpt
+// 短连接的形式请求api
+// 关于如何关闭http connection
+// https:// www.cnblogs.com/cobbliu/p/4517598.html
+func (s *Service) Do(method string, reqUrl string, opt *RequestOptions) *Reply {
+	return s.DoContext(context.Background(), method, reqUrl, opt)
+}
+
+// DoContext 与Do相同，额外接收一个context.Context用于取消请求或者传递截止时间/链路信息
+func (s *Service) DoContext(ctx context.Context, method string, reqUrl string, opt *RequestOptions) *Reply {
+	if method == "" || reqUrl == "" {
+		return &Reply{
+			Err: errors.New("request Method or request url is empty"),
+		}
+	}
+
+	if opt == nil {
+		opt = &RequestOptions{}
+	}
+
+	opt.Method = method
+	opt.Url = reqUrl
+	// client传nil，由configureClient新建，这样每次调用新建的client不会被记录到configuredClients里
+	return s.RequestContext(ctx, nil, opt)
+}
+
+// Request 请求方法
+// resty.setBody: for struct and map data type defaults to 'application/json'
+// SetBody method sets the request body for the request. It supports various realtime needs as easy.
+// We can say its quite handy or powerful. Supported request body data types is `string`,
+// `[]byte`, `struct`, `map`, `slice` and `io.Reader`. Body value can be pointer or non-pointer.
+// Automatic marshalling for JSON and XML content type, if it is `struct`, `map`, or `slice`.
+//
+// client.R().SetFormData method sets Form parameters and their values in the current request.
+// It's applicable only HTTP method `POST` and `PUT` and requests content type would be
+// set as `application/x-www-form-urlencoded`.
+func (s *Service) Request(client *resty.Client, req *RequestOptions) *Reply {
+	return s.RequestContext(context.Background(), client, req)
+}
+
+// RequestContext 与Request相同，额外接收一个context.Context，
+// 会被设置到每一次resty请求上（client.R().SetContext(ctx)），用于取消请求，传递截止时间或者链路追踪信息
+func (s *Service) RequestContext(ctx context.Context, client *resty.Client, req *RequestOptions) *Reply {
+	client = s.configureClient(client, req)
+
+	if err := s.applyAuth(client, req); err != nil {
+		return &Reply{Err: err}
+	}
+
+	host := requestHost(req.Url)
+
+	// 限流 + 并发数控制，都要在真正发出请求之前获取完成
+	release, err := s.acquire(ctx, host)
+	if err != nil {
+		return &Reply{Err: err}
+	}
+	defer release()
+
+	// 熔断器判断需要在重试之前完成，这样打开状态下的重试不会绕过熔断器
+	var cb *breaker
+	if s.breakerCfg != nil {
+		cb = s.breakerFor(host)
+		if !cb.allow() {
+			return &Reply{
+				Err:        ErrCircuitOpen,
+				StatusCode: http.StatusServiceUnavailable,
+			}
+		}
+	}
+
+	reply := s.dispatch(ctx, client, req)
+	if s.tokenSource != nil && req.BearerToken == "" && reply.StatusCode == http.StatusUnauthorized {
+		// 令牌可能已经过期或被吊销，失效缓存后重试一次。
+		// 这是一次跨Execute的重新分发，不是resty内部的重试，SetRetryResetReaders
+		// 不会覆盖到这里——如果req.FileReader在第一次dispatch时已经被读到EOF，
+		// 必须在这里手动seek回起始位置，否则第二次上传会是空body或者被截断的body
+		if seekErr := rewindFileReader(req); seekErr != nil {
+			reply.Err = seekErr
+		} else {
+			s.invalidateToken()
+			if authErr := s.applyAuth(client, req); authErr == nil {
+				reply = s.dispatch(ctx, client, req)
+			}
+		}
+	}
+
+	if cb != nil {
+		cb.record(!isFailure(reply))
+	}
+
+	return reply
+}
+
+// fileResendPossible 判断当前请求是否可能被重新发送一遍请求体：
+// 要么是resty自身按RetryCount做的重试，要么是401时失效令牌后的重新分发（见RequestContext）
+func (s *Service) fileResendPossible(req *RequestOptions) bool {
+	return req.RetryCount > 0 || (s.tokenSource != nil && req.BearerToken == "")
+}
+
+// rewindFileReader 在401令牌刷新后的重新分发之前，把req.FileReader seek回起始位置。
+// 第一次dispatch已经把reader读到了EOF（resty往multipart body里做了一次io.Copy），
+// 不是resty内部的重试，SetRetryResetReaders(true)覆盖不到这种跨Execute的场景，
+// 必须在这里手动处理；dispatch的file分支已经保证了非seekable的reader在这种场景下直接被拒绝，
+// 所以这里的类型断言理论上总会成功
+func rewindFileReader(req *RequestOptions) error {
+	if req.FileReader == nil {
+		return nil
+	}
+
+	seeker, ok := req.FileReader.(io.ReadSeeker)
+	if !ok {
+		return errors.New("gresty: RequestOptions.FileReader must implement io.ReadSeeker " +
+			"to be resent after a 401 token refresh")
+	}
+
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("gresty: failed to rewind FileReader before resend: %w", err)
+	}
+
+	return nil
+}
+
+// dispatch 根据method分发到resty对应的请求方法上，并将结果转换成Reply
+func (s *Service) dispatch(ctx context.Context, client *resty.Client, req *RequestOptions) *Reply {
 	var resp *resty.Response
 	var err error
 	method := strings.ToLower(req.Method)
 	switch method {
 	case "get", "delete", "head":
 		client = client.SetQueryParams(s.ParseData(req.Params))
+		request := client.R().SetContext(ctx)
+		if req.EnableTrace {
+			request = request.EnableTrace()
+		}
+
+		if req.ErrorModel != nil {
+			request = request.SetError(req.ErrorModel)
+		}
+
 		if method == "get" {
-			resp, err = client.R().Get(req.Url)
-			return s.GetResult(resp, err)
+			resp, err = request.Get(req.Url)
+			return s.GetResult(resp, err, req.EnableTrace)
 		}
 
 		if method == "delete" {
-			resp, err = client.R().Delete(req.Url)
-			return s.GetResult(resp, err)
+			resp, err = request.Delete(req.Url)
+			return s.GetResult(resp, err, req.EnableTrace)
 		}
 
 		// head method
-		resp, err = client.R().Head(req.Url)
-		return s.GetResult(resp, err)
+		resp, err = request.Head(req.Url)
+		return s.GetResult(resp, err, req.EnableTrace)
 	case "post", "put", "patch":
-		request := client.R()
+		request := client.R().SetContext(ctx)
+		if req.EnableTrace {
+			request = request.EnableTrace()
+		}
+
+		if req.ErrorModel != nil {
+			request = request.SetError(req.ErrorModel)
+		}
+
 		if len(req.Data) > 0 {
 			request = request.SetFormData(s.ParseData(req.Data))
 		}
@@ -286,30 +562,67 @@ func (s *Service) Request(client *resty.Client, req *RequestOptions) *Reply {
 
 		if method == "post" {
 			resp, err = request.Post(req.Url)
-			return s.GetResult(resp, err)
+			return s.GetResult(resp, err, req.EnableTrace)
 		}
 
 		if method == "put" {
 			resp, err = request.Put(req.Url)
-			return s.GetResult(resp, err)
+			return s.GetResult(resp, err, req.EnableTrace)
 		}
 
 		// head method
 		resp, err = request.Patch(req.Url)
-		return s.GetResult(resp, err)
+		return s.GetResult(resp, err, req.EnableTrace)
 	case "file":
-		b, err := os.ReadFile(req.FileName)
-		if err != nil {
-			return &Reply{
-				Err: errors.New("read file error: " + err.Error()),
+		var fileReader io.Reader
+		fileSize := req.FileSize
+		if req.FileReader != nil {
+			// 流式上传的FileReader无法像os.ReadFile那样重新读一遍，一旦被resty的重试或者
+			// 401令牌刷新后的重新分发消费过，第二次发送就会是空body或者被截断的body，
+			// 因此这类场景下要求FileReader必须可以seek回起始位置
+			if _, seekable := req.FileReader.(io.ReadSeeker); !seekable && s.fileResendPossible(req) {
+				return &Reply{
+					Err: errors.New("gresty: RequestOptions.FileReader must implement io.ReadSeeker " +
+						"when RetryCount > 0 or a TokenSource is configured, a streamed upload cannot be resent otherwise"),
+				}
+			}
+
+			// 流式上传，避免os.ReadFile把整个文件读入内存
+			fileReader = req.FileReader
+			if fileSize <= 0 {
+				// 未设置FileSize时总字节数未知，进度回调的total按约定传-1
+				fileSize = -1
 			}
+		} else {
+			b, err := os.ReadFile(req.FileName)
+			if err != nil {
+				return &Reply{
+					Err: errors.New("read file error: " + err.Error()),
+				}
+			}
+
+			fileReader = bytes.NewReader(b)
+			fileSize = int64(len(b))
+		}
+
+		if req.OnUploadProgress != nil {
+			fileReader = &progressReader{r: fileReader, total: fileSize, onProgress: req.OnUploadProgress}
 		}
 
 		// 文件上传
-		resp, err := client.R().
-			SetFileReader(req.FileParamName, req.FileName, bytes.NewReader(b)).
+		request := client.R().SetContext(ctx)
+		if req.EnableTrace {
+			request = request.EnableTrace()
+		}
+
+		if req.ErrorModel != nil {
+			request = request.SetError(req.ErrorModel)
+		}
+
+		resp, err := request.
+			SetFileReader(req.FileParamName, req.FileName, fileReader).
 			Post(req.Url)
-		return s.GetResult(resp, err)
+		return s.GetResult(resp, err, req.EnableTrace)
 	default:
 	}
 
@@ -341,15 +654,18 @@ func (s *Service) ParseData(d map[string]interface{}) map[string]string {
 
 // GetResult 处理请求的结果statusCode,body,error.
 // 首先判断是否出错，然后判断http resp是否请求成功或有错误产生
-func (s *Service) GetResult(resp *resty.Response, err error) *Reply {
+// enableTrace对应RequestOptions.EnableTrace，只有为true时Reply.Trace才会被填充
+func (s *Service) GetResult(resp *resty.Response, err error, enableTrace bool) *Reply {
 	res := &Reply{}
 	if err != nil {
 		if resp != nil {
 			res.StatusCode = resp.StatusCode()
 			res.Body = resp.Body()
+			s.fillTraceInfo(res, resp, enableTrace)
 		}
 
-		res.Err = err
+		res.Err = classifyConnError(err)
+		res.httpErr = newHTTPError(res, resp)
 		return res
 	}
 
@@ -361,10 +677,41 @@ func (s *Service) GetResult(resp *resty.Response, err error) *Reply {
 
 	res.Body = resp.Body()
 	res.StatusCode = resp.StatusCode()
+	s.fillTraceInfo(res, resp, enableTrace)
 	if !resp.IsSuccess() || resp.IsError() {
-		res.Err = fmt.Errorf("resp error: %v", resp.Error())
+		res.httpErr = newHTTPError(res, resp)
+		// res.Err本身就带上状态码/body/解码后的业务错误，调用方直接判断
+		// reply.Err != nil并返回它也不会丢失结构化信息，不需要再额外调用HTTPError()
+		res.Err = res.httpErr
 		return res
 	}
 
 	return res
 }
+
+// fillTraceInfo 将resp上的header,cookie,耗时信息填充到Reply上，
+// enableTrace为true时才会额外填充链路追踪信息，否则Reply.Trace保持nil
+func (s *Service) fillTraceInfo(res *Reply, resp *resty.Response, enableTrace bool) {
+	res.Headers = resp.Header()
+	res.Cookies = resp.Cookies()
+	res.Duration = resp.Time()
+	res.Size = resp.Size()
+
+	if !enableTrace || resp.Request == nil {
+		return
+	}
+
+	ti := resp.Request.TraceInfo()
+	res.Trace = &TraceInfo{
+		DNSLookup:     ti.DNSLookup,
+		ConnTime:      ti.ConnTime,
+		TCPConnTime:   ti.TCPConnTime,
+		TLSHandshake:  ti.TLSHandshake,
+		ServerTime:    ti.ServerTime,
+		ResponseTime:  ti.ResponseTime,
+		TotalTime:     ti.TotalTime,
+		IsConnReused:  ti.IsConnReused,
+		IsConnWasIdle: ti.IsConnWasIdle,
+		ConnIdleTime:  ti.ConnIdleTime,
+	}
+}
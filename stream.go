@@ -0,0 +1,150 @@
+package gresty
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// progressReader 包装io.Reader，每次Read时回调onProgress，用于上传进度上报
+// total未知时传-1
+type progressReader struct {
+	r          io.Reader
+	total      int64
+	readSoFar  int64
+	onProgress func(bytes, total int64)
+}
+
+// Read 实现io.Reader接口
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.readSoFar += int64(n)
+		p.onProgress(p.readSoFar, p.total)
+	}
+
+	return n, err
+}
+
+// Seek 实现io.Seeker接口，仅在底层reader本身可seek时才生效，
+// 这样progressReader不会掩盖底层reader的可seek性，配合resty的SetRetryResetReaders在重试前复位，
+// 同时把已上传字节数也复位，避免重试后进度回调从错误的基数继续累加
+func (p *progressReader) Seek(offset int64, whence int) (int64, error) {
+	rs, ok := p.r.(io.Seeker)
+	if !ok {
+		return 0, errors.New("progressReader: underlying reader does not support seeking")
+	}
+
+	n, err := rs.Seek(offset, whence)
+	if err != nil {
+		return n, err
+	}
+
+	p.readSoFar = n
+	return n, nil
+}
+
+// progressWriter 包装io.Writer，每次Write时回调onProgress，用于下载进度上报
+// total未知时传-1
+type progressWriter struct {
+	w            io.Writer
+	total        int64
+	writtenSoFar int64
+	onProgress   func(bytes, total int64)
+}
+
+// Write 实现io.Writer接口
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	if n > 0 {
+		p.writtenSoFar += int64(n)
+		p.onProgress(p.writtenSoFar, p.total)
+	}
+
+	return n, err
+}
+
+// StreamReply 流式响应的结果
+// Body需要调用方负责Close，否则会造成连接泄漏
+type StreamReply struct {
+	StatusCode int
+	Headers    http.Header
+	Body       io.ReadCloser
+	Err        error
+}
+
+// Stream 以流式方式发起请求，响应body不会被缓冲到内存中，
+// 适用于下载大文件等场景，调用方必须负责关闭返回的StreamReply.Body
+func (s *Service) Stream(client *resty.Client, req *RequestOptions) *StreamReply {
+	client = s.configureClient(client, req)
+
+	request := client.R().SetDoNotParseResponse(true)
+	if req.EnableTrace {
+		request = request.EnableTrace()
+	}
+
+	method := strings.ToLower(req.Method)
+	if method == "" {
+		method = "get"
+	}
+
+	if len(req.Params) > 0 {
+		request = request.SetQueryParams(s.ParseData(req.Params))
+	}
+
+	var resp *resty.Response
+	var err error
+	switch method {
+	case "post":
+		resp, err = request.Post(req.Url)
+	case "put":
+		resp, err = request.Put(req.Url)
+	default:
+		resp, err = request.Get(req.Url)
+	}
+
+	if err != nil {
+		return &StreamReply{Err: err}
+	}
+
+	raw := resp.RawResponse
+	return &StreamReply{
+		StatusCode: raw.StatusCode,
+		Headers:    raw.Header,
+		Body:       raw.Body,
+	}
+}
+
+// DownloadTo 以流式方式请求opt.Url，并将响应body写入w，不会把整个响应缓冲到内存中
+// 如果opt.OnDownloadProgress不为空，会在写入过程中回调上报已下载的字节数和总字节数
+func (s *Service) DownloadTo(w io.Writer, opt *RequestOptions) (written int64, err error) {
+	reply := s.Stream(nil, opt)
+	if reply.Err != nil {
+		return 0, reply.Err
+	}
+
+	defer reply.Body.Close()
+
+	if reply.StatusCode < 200 || reply.StatusCode >= 300 {
+		return 0, fmt.Errorf("download failed, status code: %d", reply.StatusCode)
+	}
+
+	dst := w
+	if opt.OnDownloadProgress != nil {
+		total := int64(-1)
+		if cl := reply.Headers.Get("Content-Length"); cl != "" {
+			if n, convErr := strconv.ParseInt(cl, 10, 64); convErr == nil {
+				total = n
+			}
+		}
+
+		dst = &progressWriter{w: w, total: total, onProgress: opt.OnDownloadProgress}
+	}
+
+	return io.Copy(dst, reply.Body)
+}
@@ -0,0 +1,98 @@
+package gresty
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName 作为otel.Tracer的名称，标识span的来源
+const tracerName = "github.com/daheige/gresty"
+
+// spanStartedKey 标记req.Context()上已经开启过span，用于startSpan识别重试场景：
+// resty对同一个*resty.Request的每一次重试都会重新调用OnBeforeRequest中间件，
+// 但context在多次尝试之间是同一个、层层SetContext下来的，没有这个标记的话每次重试
+// 都会在上一次的span下再开一个子span，导致一条逻辑请求产生N个嵌套span，且前N-1个永远不会被结束
+type spanStartedKey struct{}
+
+// WithTracer 给Service接入OpenTelemetry链路追踪
+// 每次请求都会开启一个名为"HTTP <METHOD>"的span，记录http.url/http.method/net.peer.name属性，
+// 并通过W3C traceparent header把span上下文传递给下游服务
+//
+// span通过client.OnSuccess/client.OnError结束，而不是OnAfterResponse：
+// resty在请求出错，或者SetDoNotParseResponse(true)（Stream/DownloadTo用到）时都会跳过
+// afterResponse中间件，但OnSuccess/OnError两者之一总会在每次Execute完成后被调用一次，
+// 这样才能保证span在所有完成路径上都被结束，不会泄漏
+func WithTracer(tp trace.TracerProvider) Option {
+	return func(s *Service) {
+		s.tracer = tp.Tracer(tracerName)
+		s.OnBeforeRequest(s.startSpan)
+		s.OnSuccess(s.endSpanOnSuccess)
+		s.OnError(s.endSpanOnError)
+	}
+}
+
+// startSpan 请求发出前启动span，并把span上下文注入到请求header中
+// 同一个逻辑请求的重试尝试会复用req.Context()再次触发这个中间件，spanStartedKey
+// 保证span只在第一次尝试时创建一次，后续重试直接跳过
+func (s *Service) startSpan(_ *resty.Client, req *resty.Request) error {
+	if req.Context().Value(spanStartedKey{}) != nil {
+		return nil
+	}
+
+	ctx, span := s.tracer.Start(req.Context(), "HTTP "+req.Method)
+
+	attrs := []attribute.KeyValue{
+		attribute.String("http.url", req.URL),
+		attribute.String("http.method", req.Method),
+	}
+
+	if u, err := url.Parse(req.URL); err == nil {
+		attrs = append(attrs, attribute.String("net.peer.name", u.Hostname()))
+	}
+
+	span.SetAttributes(attrs...)
+
+	ctx = context.WithValue(ctx, spanStartedKey{}, struct{}{})
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	req.SetContext(ctx)
+	return nil
+}
+
+// endSpanOnSuccess 请求成功完成后记录状态码并结束span，对应client.OnSuccess
+func (s *Service) endSpanOnSuccess(_ *resty.Client, resp *resty.Response) {
+	span := trace.SpanFromContext(resp.Request.Context())
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode()))
+	if resp.IsError() {
+		span.SetStatus(codes.Error, resp.Status())
+	}
+
+	span.End()
+}
+
+// endSpanOnError 请求最终失败（包括所有重试都已用尽）后记录错误并结束span，对应client.OnError，
+// 这是相比旧版只注册OnAfterResponse的关键修复：OnAfterResponse在传输层失败或者
+// SetDoNotParseResponse(true)（Stream/DownloadTo）时都不会被调用，span会一直泄漏
+func (s *Service) endSpanOnError(req *resty.Request, err error) {
+	span := trace.SpanFromContext(req.Context())
+	if !span.IsRecording() {
+		return
+	}
+
+	if respErr, ok := err.(*resty.ResponseError); ok {
+		span.SetAttributes(attribute.Int("http.status_code", respErr.Response.StatusCode()))
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.End()
+}